@@ -2,35 +2,401 @@ package kvstorage
 
 import (
 	"bytes"
+	"container/list"
 	"context"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/caddyserver/certmagic"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 func init() {
 	caddy.RegisterModule(KVStorage{})
 }
 
+// defaultLeaseTTL is how long a lock is considered valid after its last heartbeat.
+const defaultLeaseTTL = 90 * time.Second
+
+// defaultLeaseRefreshInterval is how often a held lock's heartbeat is renewed.
+const defaultLeaseRefreshInterval = 30 * time.Second
+
+// Retry defaults, mirroring common exponential-backoff-with-jitter conventions.
+const (
+	defaultRetryBaseInterval = 200 * time.Millisecond
+	defaultRetryMaxInterval  = 10 * time.Second
+	defaultRetryMaxElapsed   = 60 * time.Second
+)
+
+// Read-through cache defaults.
+const (
+	defaultCacheSize        = 1024
+	defaultCacheTTL         = 30 * time.Second
+	defaultCacheNegativeTTL = 5 * time.Second
+)
+
+// Endpoint failover defaults.
+const (
+	defaultEndpoint             = "https://us-east-1.kv.enzonix.com"
+	endpointCooldownBase        = 200 * time.Millisecond
+	endpointCooldownMax         = 30 * time.Second
+	endpointHealthCheckInterval = 15 * time.Second
+)
+
+// Transport connection-pool defaults, tuned so this workload doesn't pay
+// connection-setup cost on every request like http.DefaultTransport does.
+const (
+	defaultMaxIdleConns    = 100
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
 // KVStorage implements a Caddy storage backend using the kv-database HTTP API.
 type KVStorage struct {
-	Endpoint  string `json:"endpoint,omitempty"`
-	Namespace string `json:"namespace,omitempty"`
-	APIKey    string `json:"api_key,omitempty"`
+	// Endpoint is a single KV endpoint URL.
+	//
+	// Deprecated: use Endpoints. When Endpoints is empty, a non-empty
+	// Endpoint is folded into Endpoints at Provision time (split on commas)
+	// for backward compatibility with existing configs; when Endpoints is
+	// set, Endpoint is ignored.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Endpoints lists the KV endpoint URLs to use, in priority order. The
+	// first entry is treated as the primary for writes; all healthy
+	// entries are eligible for reads.
+	Endpoints []string `json:"endpoints,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	APIKey    string   `json:"api_key,omitempty"`
+
+	// LeaseTTL is how long a lock is considered valid after its last heartbeat
+	// before another node is allowed to reclaim it. Defaults to 90s.
+	LeaseTTL caddy.Duration `json:"lease_ttl,omitempty"`
+	// LeaseRefreshInterval is how often a held lock's heartbeat is renewed
+	// in the background. Defaults to 30s.
+	LeaseRefreshInterval caddy.Duration `json:"lease_refresh_interval,omitempty"`
+
+	// RetryBaseInterval is the starting backoff interval for retried requests.
+	// Defaults to 200ms.
+	RetryBaseInterval caddy.Duration `json:"retry_base_interval,omitempty"`
+	// RetryMaxInterval caps the exponential backoff interval. Defaults to 10s.
+	RetryMaxInterval caddy.Duration `json:"retry_max_interval,omitempty"`
+	// RetryMaxElapsed is the total time a request is allowed to spend
+	// retrying before giving up. Defaults to 60s.
+	RetryMaxElapsed caddy.Duration `json:"retry_max_elapsed,omitempty"`
+
+	// CacheSize is the maximum number of entries kept in the in-process
+	// read-through cache. Defaults to 1024.
+	CacheSize int `json:"cache_size,omitempty"`
+	// CacheTTL is how long a cached value is served before Load goes back
+	// to the KV API. Defaults to 30s.
+	CacheTTL caddy.Duration `json:"cache_ttl,omitempty"`
+	// CacheNegativeTTL is how long a cached os.ErrNotExist result is served
+	// before Load rechecks the API. Defaults to 5s.
+	CacheNegativeTTL caddy.Duration `json:"cache_negative_ttl,omitempty"`
+
+	// TLS configures mTLS and custom CA verification for the HTTP client.
+	// Leave nil to use the system trust store with no client certificate.
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// MaxIdleConns is the connection pool's max idle connections across all
+	// hosts. Defaults to 100.
+	MaxIdleConns int `json:"max_idle_conns,omitempty"`
+	// MaxConnsPerHost caps concurrent connections per endpoint. 0 means
+	// unlimited, matching net/http's default.
+	MaxConnsPerHost int `json:"max_conns_per_host,omitempty"`
+	// IdleConnTimeout is how long an idle connection is kept in the pool.
+	// Defaults to 90s.
+	IdleConnTimeout caddy.Duration `json:"idle_conn_timeout,omitempty"`
+
+	logger       *zap.Logger
+	client       *http.Client
+	ownerID      string
+	txnSupported bool
+
+	// cache is the in-process read-through cache. It is always non-nil
+	// after Provision; lock keys bypass it entirely.
+	cache     *lruCache
+	loadGroup singleflight.Group
+
+	// locks tracks background lease-renewal goroutines for locks held by
+	// this process, keyed by lock key.
+	locks sync.Map // map[string]*lockHandle
+
+	// endpointHealth tracks consecutive failures per entry in Endpoints,
+	// in the same order, for the round-robin+health-aware selector.
+	endpointHealth []*endpointHealth
+	endpointCursor uint64
+
+	healthCheckStop chan struct{}
+
+	// certReloader holds the client keypair when tls.client_cert/client_key
+	// are configured, so it can be swapped out by the reload goroutine
+	// without tearing down the transport.
+	certReloader  *certReloader
+	tlsReloadStop chan struct{}
+}
+
+// TLSConfig configures the mTLS/custom-CA settings used when connecting to
+// the kv-database endpoints.
+type TLSConfig struct {
+	// CAFile is a PEM-encoded CA bundle used instead of the system trust
+	// store to verify the server's certificate.
+	CAFile string `json:"ca_file,omitempty"`
+	// ClientCertFile and ClientKeyFile are a PEM-encoded keypair presented
+	// to the server for mTLS.
+	ClientCertFile string `json:"client_cert,omitempty"`
+	ClientKeyFile  string `json:"client_key,omitempty"`
+	// ServerName overrides the SNI/verification hostname, useful when the
+	// endpoint URL doesn't match the certificate's subject.
+	ServerName string `json:"server_name,omitempty"`
+	// InsecureSkipVerify disables server certificate verification. Only
+	// for testing against a private deployment with a self-signed cert.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// ReloadInterval, when set, periodically reloads ClientCertFile and
+	// ClientKeyFile from disk so a rotated certificate is picked up
+	// without a Caddy restart. Disabled by default.
+	ReloadInterval caddy.Duration `json:"reload_interval,omitempty"`
+}
+
+// certReloader serves a client certificate keypair that can be refreshed
+// from disk in the background, independent of the *tls.Config referencing it.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load client keypair: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+// GetClientCertificate implements the tls.Config.GetClientCertificate hook.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// endpointHealth tracks the failure state of a single endpoint so the
+// selector can skip it during an exponentially growing cooldown instead of
+// waiting for organic traffic to notice it recovered.
+type endpointHealth struct {
+	mu                  sync.Mutex
+	lastFailure         time.Time
+	consecutiveFailures int
+}
+
+func (h *endpointHealth) down() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.consecutiveFailures == 0 {
+		return false
+	}
+	return time.Since(h.lastFailure) < cooldownFor(h.consecutiveFailures)
+}
+
+func (h *endpointHealth) recordSuccess() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.consecutiveFailures = 0
+}
+
+func (h *endpointHealth) recordFailure() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.consecutiveFailures++
+	h.lastFailure = time.Now()
+}
+
+// cooldownFor returns how long an endpoint is skipped after consecutive
+// failures, doubling from endpointCooldownBase up to endpointCooldownMax.
+func cooldownFor(consecutiveFailures int) time.Duration {
+	d := endpointCooldownBase
+	for i := 1; i < consecutiveFailures && d < endpointCooldownMax; i++ {
+		d *= 2
+	}
+	if d > endpointCooldownMax {
+		d = endpointCooldownMax
+	}
+	return d
+}
 
-	logger *zap.Logger
-	client *http.Client
+// lockHandle tracks the background renewal goroutine for a single held lock.
+type lockHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// lockPayload is the JSON value stored at a lock key.
+type lockPayload struct {
+	OwnerID     string `json:"owner_id"`
+	AcquiredAt  int64  `json:"acquired_at"`
+	HeartbeatAt int64  `json:"heartbeat_at"`
+}
+
+// cacheEntry is a single value held by the read-through cache.
+type cacheEntry struct {
+	value     []byte
+	notFound  bool
+	expiresAt time.Time
+}
+
+// lruCache is a small fixed-capacity, TTL-aware LRU cache keyed by
+// namespace+"/"+key. It exists so Load can serve hot keys (OCSP staples,
+// the default cert lookup during TLS handshakes) without a round-trip to
+// the KV API on every call.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruCacheItem struct {
+	key   string
+	entry cacheEntry
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	item := el.Value.(*lruCacheItem)
+	if time.Now().After(item.entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return cacheEntry{}, false
+	}
+	c.ll.MoveToFront(el)
+	return item.entry, true
+}
+
+func (c *lruCache) set(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruCacheItem).entry = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&lruCacheItem{key: key, entry: entry})
+	c.items[key] = el
+
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruCacheItem).key)
+		}
+	}
+}
+
+func (c *lruCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// KVOp is a single operation within an atomic transaction submitted to Txn.
+// Verb is one of "set", "delete", "cas", "check-exists", or "check-absent".
+// IfVersion is only consulted for "cas" and lets a caller make a write
+// conditional on the key's current version.
+type KVOp struct {
+	Verb string `json:"verb"`
+	Key  string `json:"key"`
+	// Value is intentionally not omitempty: Store always emits base64 even
+	// for a zero-length value, and omitting it here would make a "set" of
+	// an empty value indistinguishable from an op that supplies none.
+	Value     []byte  `json:"value"`
+	IfVersion *uint64 `json:"if_version,omitempty"`
+}
+
+// KVOpResult is the outcome of a single KVOp within a transaction.
+type KVOpResult struct {
+	Verb    string `json:"verb"`
+	Key     string `json:"key"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// KVTxnError describes why a single op within a transaction failed.
+type KVTxnError struct {
+	OpIndex int
+	Verb    string
+	Key     string
+	Message string
+}
+
+func (e *KVTxnError) Error() string {
+	return fmt.Sprintf("op %d (%s %s): %s", e.OpIndex, e.Verb, e.Key, e.Message)
+}
+
+// KVTxnErrors aggregates the failed ops of a transaction so callers can
+// identify exactly which ops were rejected.
+type KVTxnErrors struct {
+	Errors []*KVTxnError
+}
+
+func (e *KVTxnErrors) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, opErr := range e.Errors {
+		parts[i] = opErr.Error()
+	}
+	return fmt.Sprintf("transaction failed: %s", strings.Join(parts, "; "))
 }
 
 // CaddyModule returns the Caddy module information.
@@ -45,22 +411,327 @@ func (KVStorage) CaddyModule() caddy.ModuleInfo {
 func (s *KVStorage) Provision(ctx caddy.Context) error {
 	s.logger = ctx.Logger(s)
 
-	// Default endpoint if not provided
-	if s.Endpoint == "" {
-		s.Endpoint = "https://us-east-1.kv.enzonix.com"
+	// Deprecated single-endpoint field: fold into Endpoints for backward
+	// compatibility, splitting on commas so existing configs that packed
+	// multiple URLs into one string keep working too.
+	if len(s.Endpoints) == 0 && s.Endpoint != "" {
+		for _, e := range strings.Split(s.Endpoint, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				s.Endpoints = append(s.Endpoints, e)
+			}
+		}
+	}
+	if len(s.Endpoints) == 0 {
+		s.Endpoints = []string{defaultEndpoint}
+	}
+	for i, e := range s.Endpoints {
+		s.Endpoints[i] = strings.TrimSuffix(e, "/")
+	}
+	s.endpointHealth = make([]*endpointHealth, len(s.Endpoints))
+	for i := range s.endpointHealth {
+		s.endpointHealth[i] = &endpointHealth{}
+	}
+
+	if s.LeaseTTL == 0 {
+		s.LeaseTTL = caddy.Duration(defaultLeaseTTL)
+	}
+	if s.LeaseRefreshInterval == 0 {
+		s.LeaseRefreshInterval = caddy.Duration(defaultLeaseRefreshInterval)
+	}
+
+	if s.RetryBaseInterval == 0 {
+		s.RetryBaseInterval = caddy.Duration(defaultRetryBaseInterval)
+	}
+	if s.RetryMaxInterval == 0 {
+		s.RetryMaxInterval = caddy.Duration(defaultRetryMaxInterval)
+	}
+	if s.RetryMaxElapsed == 0 {
+		s.RetryMaxElapsed = caddy.Duration(defaultRetryMaxElapsed)
+	}
+
+	if s.CacheSize == 0 {
+		s.CacheSize = defaultCacheSize
+	}
+	if s.CacheTTL == 0 {
+		s.CacheTTL = caddy.Duration(defaultCacheTTL)
+	}
+	if s.CacheNegativeTTL == 0 {
+		s.CacheNegativeTTL = caddy.Duration(defaultCacheNegativeTTL)
 	}
+	s.cache = newLRUCache(s.CacheSize)
 
-	// Ensure endpoint doesn't end with a slash
-	s.Endpoint = strings.TrimSuffix(s.Endpoint, "/")
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	var nonce [8]byte
+	if _, err := cryptorand.Read(nonce[:]); err != nil {
+		binary.BigEndian.PutUint64(nonce[:], uint64(time.Now().UnixNano()))
+	}
+	s.ownerID = fmt.Sprintf("%s-%d-%x", hostname, os.Getpid(), nonce)
+
+	if s.MaxIdleConns == 0 {
+		s.MaxIdleConns = defaultMaxIdleConns
+	}
+	if s.IdleConnTimeout == 0 {
+		s.IdleConnTimeout = caddy.Duration(defaultIdleConnTimeout)
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns: s.MaxIdleConns,
+		// This client talks to a handful of endpoints, so give each one
+		// the same idle-connection budget as the pool overall rather than
+		// net/http's default of 2 per host.
+		MaxIdleConnsPerHost: s.MaxIdleConns,
+		MaxConnsPerHost:     s.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(s.IdleConnTimeout),
+	}
+
+	if s.TLS != nil {
+		tlsConfig, err := s.buildTLSConfig()
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+
+		if s.TLS.ReloadInterval > 0 && s.certReloader != nil {
+			s.tlsReloadStop = make(chan struct{})
+			go s.runCertReload(s.tlsReloadStop, time.Duration(s.TLS.ReloadInterval))
+		}
+	}
 
 	// Create HTTP client with timeout
 	s.client = &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	s.txnSupported = s.probeTxnSupport(probeCtx)
+	if !s.txnSupported {
+		s.logger.Info("kv-database does not support /api/txn, falling back to serial operations")
+	}
+
+	s.healthCheckStop = make(chan struct{})
+	go s.runHealthChecks(s.healthCheckStop)
+
+	return nil
+}
+
+// buildTLSConfig translates TLS into a *tls.Config, loading the CA bundle
+// and client keypair from disk.
+func (s *KVStorage) buildTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: s.TLS.InsecureSkipVerify,
+		ServerName:         s.TLS.ServerName,
+	}
+
+	if s.TLS.CAFile != "" {
+		caCert, err := os.ReadFile(s.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file %q: %w", s.TLS.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificates found in ca_file %q", s.TLS.CAFile)
+		}
+		cfg.RootCAs = pool
 	}
 
+	if s.TLS.ClientCertFile != "" && s.TLS.ClientKeyFile != "" {
+		reloader, err := newCertReloader(s.TLS.ClientCertFile, s.TLS.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.certReloader = reloader
+		cfg.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	return cfg, nil
+}
+
+// runCertReload periodically reloads the client keypair from disk so a
+// rotated certificate is picked up without a Caddy restart.
+func (s *KVStorage) runCertReload(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.certReloader.reload(); err != nil {
+				s.logger.Error("failed to reload client certificate", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Cleanup stops the background endpoint health-check and TLS-reload
+// goroutines started in Provision, along with the lease-renewal goroutine
+// for every lock still held by this process - otherwise a config reload
+// with a lock held would leak both the goroutine and its periodic Stores.
+func (s *KVStorage) Cleanup() error {
+	if s.healthCheckStop != nil {
+		close(s.healthCheckStop)
+	}
+	if s.tlsReloadStop != nil {
+		close(s.tlsReloadStop)
+	}
+	s.locks.Range(func(key, handleVal interface{}) bool {
+		handle := handleVal.(*lockHandle)
+		handle.cancel()
+		<-handle.done
+		s.locks.Delete(key)
+		return true
+	})
 	return nil
 }
 
+// runHealthChecks periodically probes endpoints currently in their cooldown
+// window so a recovered endpoint doesn't have to wait for organic traffic
+// to notice it's back.
+func (s *KVStorage) runHealthChecks(stop <-chan struct{}) {
+	ticker := time.NewTicker(endpointHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for i, endpoint := range s.Endpoints {
+				if s.endpointHealth[i].down() {
+					s.probeEndpointHealth(i, endpoint)
+				}
+			}
+		}
+	}
+}
+
+// probeEndpointHealth issues a cheap read against endpoint and, if it
+// responds without a server error, marks it healthy again.
+func (s *KVStorage) probeEndpointHealth(idx int, endpoint string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	urlStr := fmt.Sprintf("%s/api/read/%s", endpoint, url.PathEscape(s.Namespace))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusInternalServerError {
+		s.endpointHealth[idx].recordSuccess()
+		s.logger.Info("endpoint recovered", zap.String("endpoint", endpoint))
+	}
+}
+
+// endpointOrder returns the indexes into Endpoints to try, in order. When
+// preferPrimary is true (Store/Delete) the first healthy endpoint is
+// preferred and index 0 is always tried first if it isn't cooling down;
+// otherwise (Load/listKeys) healthy endpoints are tried round-robin before
+// any endpoint currently in its failure cooldown.
+func (s *KVStorage) endpointOrder(preferPrimary bool) []int {
+	n := len(s.Endpoints)
+	order := make([]int, n)
+
+	if preferPrimary {
+		for i := range order {
+			order[i] = i
+		}
+	} else {
+		start := int(atomic.AddUint64(&s.endpointCursor, 1) % uint64(n))
+		for i := range order {
+			order[i] = (start + i) % n
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return !s.endpointHealth[order[i]].down() && s.endpointHealth[order[j]].down()
+	})
+	return order
+}
+
+// requestWithFailover builds and executes a request against each endpoint
+// in endpointOrder(preferPrimary), in turn, until one succeeds or all have
+// been tried. build is called once per attempted endpoint so it can rebuild
+// the request body (a *bytes.Buffer request body can't be reused).
+//
+// Non-idempotent requests (isIdempotent=false, used for Store/Txn)
+// never fail over: once doWithRetry returns, either the write has already
+// reached the server (an error after that point can't safely be retried
+// anywhere, let alone against a different endpoint) or it hasn't (in which
+// case preferPrimary has already chosen the one endpoint it's allowed to
+// try). Only idempotent requests (Load/listKeys, and Delete) fan out
+// across endpoints.
+func (s *KVStorage) requestWithFailover(ctx context.Context, isIdempotent, preferPrimary bool, build func(endpoint string) (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+	for _, idx := range s.endpointOrder(preferPrimary) {
+		endpoint := s.Endpoints[idx]
+
+		req, err := build(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := s.doWithRetry(ctx, req, isIdempotent)
+		switch {
+		case err == nil && isRetryableStatus(resp.StatusCode):
+			// doWithRetry only returns a retryable status without an error
+			// for a non-idempotent request (see its isIdempotent gating),
+			// meaning the write already reached the server; the response
+			// is returned to the caller as-is, but it's still a sign this
+			// endpoint is unhealthy.
+			s.endpointHealth[idx].recordFailure()
+			return resp, nil
+		case err == nil:
+			s.endpointHealth[idx].recordSuccess()
+			return resp, nil
+		default:
+			s.endpointHealth[idx].recordFailure()
+			lastErr = err
+		}
+
+		if !isIdempotent {
+			return nil, fmt.Errorf("request to %s failed: %w", endpoint, lastErr)
+		}
+
+		s.logger.Warn("endpoint request failed, trying next endpoint",
+			zap.String("endpoint", endpoint), zap.Error(lastErr))
+	}
+	return nil, fmt.Errorf("all endpoints failed: %w", lastErr)
+}
+
+// probeTxnSupport checks whether the primary endpoint supports the
+// transaction API, so Lock and BatchedStore can fall back to serial calls
+// against older servers instead of failing outright.
+func (s *KVStorage) probeTxnSupport(ctx context.Context) bool {
+	urlStr := fmt.Sprintf("%s/api/txn", s.Endpoints[0])
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, urlStr, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusMethodNotAllowed
+}
+
 // Validate validates the configuration.
 func (s *KVStorage) Validate() error {
 	if s.Namespace == "" {
@@ -81,8 +752,28 @@ func (s *KVStorage) CertMagicStorage() (certmagic.Storage, error) {
 //
 //	storage enzonix_kv {
 //	    endpoint <url>
+//	    endpoint <url2>
 //	    namespace <namespace>
 //	    api_key <key>
+//	    lease_ttl <duration>
+//	    lease_refresh_interval <duration>
+//	    retry_base_interval <duration>
+//	    retry_max_interval <duration>
+//	    retry_max_elapsed <duration>
+//	    cache_size <count>
+//	    cache_ttl <duration>
+//	    cache_negative_ttl <duration>
+//	    max_idle_conns <count>
+//	    max_conns_per_host <count>
+//	    idle_conn_timeout <duration>
+//	    tls {
+//	        ca_file <path>
+//	        client_cert <path>
+//	        client_key <path>
+//	        server_name <name>
+//	        insecure_skip_verify <bool>
+//	        reload_interval <duration>
+//	    }
 //	}
 func (s *KVStorage) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 	for d.Next() {
@@ -96,7 +787,7 @@ func (s *KVStorage) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 				if !d.NextArg() {
 					return d.ArgErr()
 				}
-				s.Endpoint = d.Val()
+				s.Endpoints = append(s.Endpoints, d.Val())
 			case "namespace":
 				if !d.NextArg() {
 					return d.ArgErr()
@@ -107,6 +798,154 @@ func (s *KVStorage) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 					return d.ArgErr()
 				}
 				s.APIKey = d.Val()
+			case "lease_ttl":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing lease_ttl: %v", err)
+				}
+				s.LeaseTTL = caddy.Duration(dur)
+			case "lease_refresh_interval":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing lease_refresh_interval: %v", err)
+				}
+				s.LeaseRefreshInterval = caddy.Duration(dur)
+			case "retry_base_interval":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing retry_base_interval: %v", err)
+				}
+				s.RetryBaseInterval = caddy.Duration(dur)
+			case "retry_max_interval":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing retry_max_interval: %v", err)
+				}
+				s.RetryMaxInterval = caddy.Duration(dur)
+			case "retry_max_elapsed":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing retry_max_elapsed: %v", err)
+				}
+				s.RetryMaxElapsed = caddy.Duration(dur)
+			case "cache_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("parsing cache_size: %v", err)
+				}
+				s.CacheSize = size
+			case "cache_ttl":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing cache_ttl: %v", err)
+				}
+				s.CacheTTL = caddy.Duration(dur)
+			case "cache_negative_ttl":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing cache_negative_ttl: %v", err)
+				}
+				s.CacheNegativeTTL = caddy.Duration(dur)
+			case "max_idle_conns":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("parsing max_idle_conns: %v", err)
+				}
+				s.MaxIdleConns = n
+			case "max_conns_per_host":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("parsing max_conns_per_host: %v", err)
+				}
+				s.MaxConnsPerHost = n
+			case "idle_conn_timeout":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("parsing idle_conn_timeout: %v", err)
+				}
+				s.IdleConnTimeout = caddy.Duration(dur)
+			case "tls":
+				if s.TLS == nil {
+					s.TLS = &TLSConfig{}
+				}
+				for nesting := d.Nesting(); d.NextBlock(nesting); {
+					switch d.Val() {
+					case "ca_file":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						s.TLS.CAFile = d.Val()
+					case "client_cert":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						s.TLS.ClientCertFile = d.Val()
+					case "client_key":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						s.TLS.ClientKeyFile = d.Val()
+					case "server_name":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						s.TLS.ServerName = d.Val()
+					case "insecure_skip_verify":
+						if d.NextArg() {
+							b, err := strconv.ParseBool(d.Val())
+							if err != nil {
+								return d.Errf("parsing insecure_skip_verify: %v", err)
+							}
+							s.TLS.InsecureSkipVerify = b
+						} else {
+							s.TLS.InsecureSkipVerify = true
+						}
+					case "reload_interval":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						dur, err := caddy.ParseDuration(d.Val())
+						if err != nil {
+							return d.Errf("parsing reload_interval: %v", err)
+						}
+						s.TLS.ReloadInterval = caddy.Duration(dur)
+					default:
+						return d.Errf("unrecognized tls subdirective: %s", d.Val())
+					}
+				}
 			default:
 				return d.Errf("unrecognized subdirective: %s", d.Val())
 			}
@@ -128,6 +967,144 @@ func (s *KVStorage) parseErrorResponse(body []byte) string {
 	return string(body)
 }
 
+// isRetryableStatus reports whether an HTTP status code warrants a retry.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfterDelay parses a Retry-After header (either delay-seconds or an
+// HTTP-date) into a duration, returning 0 if absent or unparsable.
+func retryAfterDelay(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(header); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoffWithJitter computes a full-jitter exponential backoff delay for the
+// given attempt (0-indexed), capped at maxInterval.
+func backoffWithJitter(attempt int, base, maxInterval time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < maxInterval; i++ {
+		delay *= 2
+	}
+	if delay > maxInterval {
+		delay = maxInterval
+	}
+	return time.Duration(rand.Float64() * float64(delay))
+}
+
+// isRetryableError reports whether err represents a transient, retryable
+// transport failure (as opposed to a successful round-trip with a non-2xx
+// status, which is classified separately by status code).
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// doWithRetry executes req via s.client, retrying transient failures with
+// exponential backoff and full jitter. It retries on network-level errors
+// and on HTTP 429/500/502/503/504, honoring Retry-After when present and the
+// caller's ctx between sleeps. Non-idempotent requests (isIdempotent=false,
+// used for Store's and Txn's POST) are never retried: http.Client.Do returns
+// a nil *http.Response for every transport-level error, so there is no
+// signal available here that distinguishes "nothing was sent" from "the
+// write reached the server and the connection then broke," and a 429/5xx
+// status means the server has already seen the request. Either case risks
+// applying a write twice, so a non-idempotent request's first failure, of
+// either kind, is returned to the caller as-is.
+func (s *KVStorage) doWithRetry(ctx context.Context, req *http.Request, isIdempotent bool) (*http.Response, error) {
+	baseInterval := time.Duration(s.RetryBaseInterval)
+	maxInterval := time.Duration(s.RetryMaxInterval)
+	maxElapsed := time.Duration(s.RetryMaxElapsed)
+
+	start := time.Now()
+	attemptReq := req
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			cloned := req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("failed to rewind request body for retry: %w", err)
+				}
+				cloned.Body = io.NopCloser(body)
+			}
+			attemptReq = cloned
+		}
+
+		resp, err := s.client.Do(attemptReq)
+
+		var retryAfter time.Duration
+		retry := false
+		switch {
+		case err != nil:
+			retry = isIdempotent && isRetryableError(err)
+		case isRetryableStatus(resp.StatusCode):
+			// A non-idempotent request (Store, Txn) has definitely reached
+			// the server by the time we have a status code, so retrying it
+			// here could double-apply a write that the server already
+			// processed before replying with a transient-looking status.
+			retry = isIdempotent
+			retryAfter = retryAfterDelay(resp.Header.Get("Retry-After"))
+		}
+
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		elapsed := time.Since(start)
+		if elapsed >= maxElapsed {
+			if err != nil {
+				return nil, fmt.Errorf("giving up after %d attempt(s): %w", attempt+1, err)
+			}
+			return resp, fmt.Errorf("giving up after %d attempt(s): status %d", attempt+1, resp.StatusCode)
+		}
+
+		wait := backoffWithJitter(attempt, baseInterval, maxInterval)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if remaining := maxElapsed - elapsed; wait > remaining {
+			wait = remaining
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
 // Store stores a value at the given key.
 func (s *KVStorage) Store(ctx context.Context, key string, value []byte) error {
 	// Base64 encode the value to preserve binary data integrity
@@ -143,18 +1120,20 @@ func (s *KVStorage) Store(ctx context.Context, key string, value []byte) error {
 		return fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	// Create request - URL encode namespace and key to handle special characters
-	urlStr := fmt.Sprintf("%s/api/write/%s/%s", s.Endpoint, url.PathEscape(s.Namespace), url.PathEscape(key))
-	req, err := http.NewRequestWithContext(ctx, "POST", urlStr, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
-
-	// Execute request
-	resp, err := s.client.Do(req)
+	// Execute request against the primary endpoint, or the first healthy
+	// one while the primary is cooling down. A write is not idempotent, so a
+	// failed request is neither retried nor sent to another endpoint.
+	resp, err := s.requestWithFailover(ctx, false, true, func(endpoint string) (*http.Request, error) {
+		// URL encode namespace and key to handle special characters
+		urlStr := fmt.Sprintf("%s/api/write/%s/%s", endpoint, url.PathEscape(s.Namespace), url.PathEscape(key))
+		req, err := http.NewRequestWithContext(ctx, "POST", urlStr, bytes.NewBuffer(jsonBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -182,22 +1161,71 @@ func (s *KVStorage) Store(ctx context.Context, key string, value []byte) error {
 		}
 	}
 
+	if s.cache != nil {
+		s.cache.delete(s.Namespace + "/" + key)
+	}
+
 	return nil
 }
 
-// Load retrieves a value for the given key.
+// Load retrieves a value for the given key, serving from the read-through
+// cache when possible and collapsing concurrent misses for the same key
+// into a single request via singleflight. Lock keys always bypass the
+// cache so the locking protocol never sees a stale value.
 func (s *KVStorage) Load(ctx context.Context, key string) ([]byte, error) {
-	// Create request - URL encode namespace and key to handle special characters
-	urlStr := fmt.Sprintf("%s/api/read/%s/%s", s.Endpoint, url.PathEscape(s.Namespace), url.PathEscape(key))
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if s.cache == nil || strings.HasSuffix(key, ".lock") {
+		return s.loadUncached(ctx, key)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+	cacheKey := s.Namespace + "/" + key
+	if entry, ok := s.cache.get(cacheKey); ok {
+		if entry.notFound {
+			s.logger.Debug("cache hit (negative)", zap.String("key", key))
+			return nil, os.ErrNotExist
+		}
+		s.logger.Debug("cache hit", zap.String("key", key))
+		return entry.value, nil
+	}
 
-	// Execute request
-	resp, err := s.client.Do(req)
+	v, err, _ := s.loadGroup.Do(cacheKey, func() (interface{}, error) {
+		value, loadErr := s.loadUncached(ctx, key)
+		if loadErr != nil {
+			if loadErr == os.ErrNotExist {
+				s.cache.set(cacheKey, cacheEntry{
+					notFound:  true,
+					expiresAt: time.Now().Add(time.Duration(s.CacheNegativeTTL)),
+				})
+			}
+			return nil, loadErr
+		}
+		s.cache.set(cacheKey, cacheEntry{
+			value:     value,
+			expiresAt: time.Now().Add(time.Duration(s.CacheTTL)),
+		})
+		s.logger.Debug("cache miss", zap.String("key", key))
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// loadUncached performs the actual HTTP round-trip to fetch key, bypassing
+// the read-through cache.
+func (s *KVStorage) loadUncached(ctx context.Context, key string) ([]byte, error) {
+	// Reads are safe to retry against any healthy endpoint, so this is
+	// round-robin rather than primary-preferred.
+	resp, err := s.requestWithFailover(ctx, true, false, func(endpoint string) (*http.Request, error) {
+		// URL encode namespace and key to handle special characters
+		urlStr := fmt.Sprintf("%s/api/read/%s/%s", endpoint, url.PathEscape(s.Namespace), url.PathEscape(key))
+		req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -237,23 +1265,25 @@ func (s *KVStorage) Load(ctx context.Context, key string) ([]byte, error) {
 
 // Delete deletes the value at the given key.
 func (s *KVStorage) Delete(ctx context.Context, key string) error {
-	// Create request - URL encode namespace and key to handle special characters
-	urlStr := fmt.Sprintf("%s/api/write/%s/%s", s.Endpoint, url.PathEscape(s.Namespace), url.PathEscape(key))
-	req, err := http.NewRequestWithContext(ctx, "DELETE", urlStr, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
-
-	// Execute request
-	resp, err := s.client.Do(req)
+	resp, err := s.requestWithFailover(ctx, true, true, func(endpoint string) (*http.Request, error) {
+		// URL encode namespace and key to handle special characters
+		urlStr := fmt.Sprintf("%s/api/write/%s/%s", endpoint, url.PathEscape(s.Namespace), url.PathEscape(key))
+		req, err := http.NewRequestWithContext(ctx, "DELETE", urlStr, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
+		if s.cache != nil {
+			s.cache.delete(s.Namespace + "/" + key)
+		}
 		return os.ErrNotExist
 	}
 
@@ -263,22 +1293,25 @@ func (s *KVStorage) Delete(ctx context.Context, key string) error {
 		return fmt.Errorf("delete request failed with status %d: %s", resp.StatusCode, errorMsg)
 	}
 
+	if s.cache != nil {
+		s.cache.delete(s.Namespace + "/" + key)
+	}
+
 	return nil
 }
 
 // listKeys returns all keys that have the given prefix.
 func (s *KVStorage) listKeys(ctx context.Context, prefix string, recursive bool) ([]string, error) {
-	// Get all keys in the namespace - URL encode namespace to handle special characters
-	urlStr := fmt.Sprintf("%s/api/read/%s", s.Endpoint, url.PathEscape(s.Namespace))
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
-
-	// Execute request
-	resp, err := s.client.Do(req)
+	resp, err := s.requestWithFailover(ctx, true, false, func(endpoint string) (*http.Request, error) {
+		// Get all keys in the namespace - URL encode namespace to handle special characters
+		urlStr := fmt.Sprintf("%s/api/read/%s", endpoint, url.PathEscape(s.Namespace))
+		req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -355,56 +1388,267 @@ func (s *KVStorage) List(ctx context.Context, prefix string, recursive bool) ([]
 	return s.listKeys(ctx, prefix, recursive)
 }
 
-// Lock acquires a lock for the given key.
+// Txn executes a batch of ops atomically against the /api/txn/{namespace}
+// endpoint: either all ops apply or none do. The returned []KVOpResult
+// mirrors ops positionally; if any op failed, the returned error is a
+// *KVTxnErrors identifying which ones.
+func (s *KVStorage) Txn(ctx context.Context, ops []KVOp) ([]KVOpResult, error) {
+	reqBody := struct {
+		Ops []KVOp `json:"ops"`
+	}{Ops: ops}
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction body: %w", err)
+	}
+
+	// A transaction is not safe to retry blindly if it already applied
+	// server-side, so treat it like Store: prefer the primary endpoint, and
+	// neither retry a failed request nor send it to another endpoint.
+	resp, err := s.requestWithFailover(ctx, false, true, func(endpoint string) (*http.Request, error) {
+		urlStr := fmt.Sprintf("%s/api/txn/%s", endpoint, url.PathEscape(s.Namespace))
+		req, err := http.NewRequestWithContext(ctx, "POST", urlStr, bytes.NewBuffer(jsonBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.APIKey))
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errorMsg := s.parseErrorResponse(body)
+		return nil, fmt.Errorf("txn request failed with status %d: %s", resp.StatusCode, errorMsg)
+	}
+
+	var result struct {
+		Results []KVOpResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var txnErrs KVTxnErrors
+	for i, opResult := range result.Results {
+		if !opResult.Success {
+			txnErrs.Errors = append(txnErrs.Errors, &KVTxnError{
+				OpIndex: i,
+				Verb:    opResult.Verb,
+				Key:     opResult.Key,
+				Message: opResult.Error,
+			})
+		}
+	}
+	if len(txnErrs.Errors) > 0 {
+		return result.Results, &txnErrs
+	}
+
+	// The transaction applied atomically, so every mutating op's key may
+	// now have a different value (or no value) than whatever Load cached.
+	if s.cache != nil {
+		for _, op := range ops {
+			switch op.Verb {
+			case "set", "delete", "cas":
+				s.cache.delete(s.Namespace + "/" + op.Key)
+			}
+		}
+	}
+
+	return result.Results, nil
+}
+
+// BatchedStore writes items atomically via Txn when the server supports it,
+// ensuring related keys (e.g. a cert, its private key, and metadata) never
+// land partially on a mid-flight crash. It falls back to serial Store calls
+// against servers without transaction support.
+func (s *KVStorage) BatchedStore(ctx context.Context, items map[string][]byte) error {
+	if !s.txnSupported {
+		for key, value := range items {
+			if err := s.Store(ctx, key, value); err != nil {
+				return fmt.Errorf("failed to store key %q: %w", key, err)
+			}
+		}
+		return nil
+	}
+
+	ops := make([]KVOp, 0, len(items))
+	for key, value := range items {
+		ops = append(ops, KVOp{Verb: "set", Key: key, Value: value})
+	}
+
+	_, err := s.Txn(ctx, ops)
+	if err != nil {
+		return fmt.Errorf("batched store failed: %w", err)
+	}
+	return nil
+}
+
+// loadLockPayload loads and parses the lease payload at lockKey. It returns
+// os.ErrNotExist if no lock is present.
+func (s *KVStorage) loadLockPayload(ctx context.Context, lockKey string) (lockPayload, error) {
+	raw, err := s.Load(ctx, lockKey)
+	if err != nil {
+		return lockPayload{}, err
+	}
+	var payload lockPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return lockPayload{}, fmt.Errorf("failed to parse lock payload: %w", err)
+	}
+	return payload, nil
+}
+
+// Lock acquires a lease-based lock for the given key and starts a background
+// goroutine that renews its heartbeat every LeaseRefreshInterval until Unlock
+// is called. A peer's lock is only considered valid while its heartbeat is
+// younger than LeaseTTL, so a crashed node's lock can be reclaimed quickly
+// instead of waiting out a fixed staleness window.
 func (s *KVStorage) Lock(ctx context.Context, key string) error {
 	lockKey := key + ".lock"
-	lockValue := fmt.Sprintf("%d", time.Now().UnixNano())
+	leaseTTL := time.Duration(s.LeaseTTL)
+
+	now := time.Now()
+	payload := lockPayload{
+		OwnerID:     s.ownerID,
+		AcquiredAt:  now.Unix(),
+		HeartbeatAt: now.Unix(),
+	}
+	jsonValue, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock payload: %w", err)
+	}
 
-	// Check if lock already exists
-	existingLock, err := s.Load(ctx, lockKey)
+	// Fast path: when the server supports transactions, a single
+	// check-absent + set op eliminates the check/create race entirely.
+	if s.txnSupported {
+		_, txnErr := s.Txn(ctx, []KVOp{
+			{Verb: "check-absent", Key: lockKey},
+			{Verb: "set", Key: lockKey, Value: jsonValue},
+		})
+		if txnErr == nil {
+			s.startLeaseRenewal(lockKey, payload.AcquiredAt)
+			return nil
+		}
+		var txnErrs *KVTxnErrors
+		if !errors.As(txnErr, &txnErrs) {
+			return fmt.Errorf("failed to acquire lock: %w", txnErr)
+		}
+		// check-absent failed, meaning the lock key already exists. Fall
+		// through to the staleness check below to decide whether it can
+		// be reclaimed.
+	}
+
+	// Check if a valid lock already exists
+	existing, err := s.loadLockPayload(ctx, lockKey)
 	if err == nil {
-		// Lock exists - check if it's stale (older than 5 minutes)
-		var lockTime int64
-		if _, parseErr := fmt.Sscanf(string(existingLock), "%d", &lockTime); parseErr == nil {
-			lockAge := time.Since(time.Unix(0, lockTime))
-			if lockAge < 5*time.Minute {
-				// Lock is still valid, cannot acquire
-				return fmt.Errorf("failed to acquire lock: lock already exists")
-			}
-			// Lock is stale, we can overwrite it
-			s.logger.Warn("overwriting stale lock", zap.String("key", lockKey), zap.Duration("age", lockAge))
-		} else {
-			// Can't parse lock value, assume it's valid
-			return fmt.Errorf("failed to acquire lock: lock already exists")
+		age := time.Since(time.Unix(existing.HeartbeatAt, 0))
+		if age < leaseTTL {
+			return fmt.Errorf("failed to acquire lock: lock already held by %s", existing.OwnerID)
 		}
+		// Lease expired, we can reclaim it
+		s.logger.Warn("reclaiming expired lock",
+			zap.String("key", lockKey),
+			zap.String("previous_owner", existing.OwnerID),
+			zap.Duration("age", age))
 	} else if err != os.ErrNotExist {
-		// Some other error occurred
 		return fmt.Errorf("failed to check lock existence: %w", err)
 	}
-	// Lock doesn't exist or is stale, try to create it
 
-	// Try to create the lock key
-	err = s.Store(ctx, lockKey, []byte(lockValue))
-	if err != nil {
+	if err := s.Store(ctx, lockKey, jsonValue); err != nil {
 		return fmt.Errorf("failed to acquire lock: %w", err)
 	}
 
 	// Verify the lock was created with our value (defense against race conditions)
-	verifyLock, err := s.Load(ctx, lockKey)
+	verify, err := s.loadLockPayload(ctx, lockKey)
 	if err != nil {
 		return fmt.Errorf("failed to verify lock: %w", err)
 	}
-	if string(verifyLock) != lockValue {
-		// Someone else created the lock between our check and create
+	if verify.OwnerID != s.ownerID {
 		return fmt.Errorf("failed to acquire lock: lock was acquired by another process")
 	}
 
+	s.startLeaseRenewal(lockKey, payload.AcquiredAt)
+
 	return nil
 }
 
-// Unlock releases the lock for the given key.
+// startLeaseRenewal launches the background goroutine that keeps lockKey's
+// heartbeat fresh for as long as this process holds it. acquiredAt is the
+// original acquisition time recorded in Lock and is carried through every
+// renewal unchanged, so acquired_at keeps reflecting when the lock was
+// first taken rather than drifting forward to match heartbeat_at.
+func (s *KVStorage) startLeaseRenewal(lockKey string, acquiredAt int64) {
+	renewCtx, cancel := context.WithCancel(context.Background())
+	handle := &lockHandle{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	s.locks.Store(lockKey, handle)
+
+	go func() {
+		defer close(handle.done)
+
+		ticker := time.NewTicker(time.Duration(s.LeaseRefreshInterval))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				payload := lockPayload{
+					OwnerID:     s.ownerID,
+					AcquiredAt:  acquiredAt,
+					HeartbeatAt: time.Now().Unix(),
+				}
+				jsonValue, err := json.Marshal(payload)
+				if err != nil {
+					s.logger.Error("failed to marshal lease heartbeat", zap.String("key", lockKey), zap.Error(err))
+					continue
+				}
+				if err := s.Store(renewCtx, lockKey, jsonValue); err != nil {
+					s.logger.Error("failed to renew lease", zap.String("key", lockKey), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Unlock stops the background lease renewal for the given key and releases
+// the lock, but only if it still belongs to this process - a lock that was
+// already reclaimed by another owner is left untouched.
 func (s *KVStorage) Unlock(ctx context.Context, key string) error {
 	lockKey := key + ".lock"
+
+	if handleVal, ok := s.locks.LoadAndDelete(lockKey); ok {
+		handle := handleVal.(*lockHandle)
+		handle.cancel()
+		<-handle.done
+	}
+
+	payload, err := s.loadLockPayload(ctx, lockKey)
+	if err != nil {
+		if err == os.ErrNotExist {
+			// Already gone, nothing to do
+			return nil
+		}
+		return fmt.Errorf("failed to check lock ownership: %w", err)
+	}
+	if payload.OwnerID != s.ownerID {
+		// Our lease expired and another process reclaimed it; do not delete
+		// a lock we no longer own.
+		s.logger.Warn("not releasing lock owned by another process",
+			zap.String("key", lockKey), zap.String("current_owner", payload.OwnerID))
+		return nil
+	}
+
 	return s.Delete(ctx, lockKey)
 }
 
@@ -413,6 +1657,7 @@ var (
 	_ certmagic.Storage      = (*KVStorage)(nil)
 	_ caddy.StorageConverter = (*KVStorage)(nil)
 	_ caddy.Provisioner      = (*KVStorage)(nil)
+	_ caddy.CleanerUpper     = (*KVStorage)(nil)
 	_ caddy.Validator        = (*KVStorage)(nil)
 	_ caddyfile.Unmarshaler  = (*KVStorage)(nil)
 )